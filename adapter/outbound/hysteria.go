@@ -9,11 +9,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
-	"regexp"
 	"strconv"
+	"sync"
 	"time"
 
+	"github.com/Dreamacro/clash/component/bandwidth"
+	hyBBR "github.com/Dreamacro/clash/component/congestion"
 	"github.com/Dreamacro/clash/component/dialer"
+	tlsC "github.com/Dreamacro/clash/component/tls"
 	C "github.com/Dreamacro/clash/constant"
 	"github.com/Dreamacro/clash/log"
 	"github.com/lucas-clemente/quic-go"
@@ -35,18 +38,49 @@ const (
 	DefaultMaxIncomingStreams      = 1024
 
 	DefaultALPN = "hysteria"
+
+	initialMaxDatagramSize = 1252
 )
 
-var rateStringRegexp = regexp.MustCompile(`^(\d+)\s*([KMGT]?)([Bb])ps$`)
+// newCongestionControlFactory picks the congestion.CongestionControl
+// constructor core.NewClient will use. "brutal" (the default) only behaves
+// well when up/down are truthful; bbr/cubic/reno all discover the usable
+// rate on their own instead of trusting the user-supplied hint.
+func newCongestionControlFactory(name string) (func(refBPS uint64) congestion.CongestionControl, error) {
+	switch name {
+	case "", "brutal":
+		return func(refBPS uint64) congestion.CongestionControl {
+			return hyCongestion.NewBrutalSender(congestion.ByteCount(refBPS))
+		}, nil
+	case "bbr":
+		return func(uint64) congestion.CongestionControl {
+			return hyBBR.NewSender()
+		}, nil
+	case "cubic":
+		return func(uint64) congestion.CongestionControl {
+			return congestion.NewCubicSender(congestion.DefaultClock{}, &congestion.RTTStats{}, initialMaxDatagramSize, false, nil)
+		}, nil
+	case "reno":
+		return func(uint64) congestion.CongestionControl {
+			return congestion.NewCubicSender(congestion.DefaultClock{}, &congestion.RTTStats{}, initialMaxDatagramSize, true, nil)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported congestion controller: %s", name)
+	}
+}
 
 type Hysteria struct {
 	*Base
 
 	client          *core.Client
 	clientTransport *transport.ClientTransport
+	fastOpen        bool
 }
 
 func (h *Hysteria) DialContext(ctx context.Context, metadata *C.Metadata, opts ...dialer.Option) (C.Conn, error) {
+	if h.fastOpen {
+		return NewConn(&hyFastOpenConn{client: h.client, addr: metadata.RemoteAddress()}, h), nil
+	}
 	tcpConn, err := h.client.DialTCP(metadata.RemoteAddress())
 	if err != nil {
 		return nil, err
@@ -55,6 +89,9 @@ func (h *Hysteria) DialContext(ctx context.Context, metadata *C.Metadata, opts .
 }
 
 func (h *Hysteria) ListenPacketContext(ctx context.Context, metadata *C.Metadata, opts ...dialer.Option) (C.PacketConn, error) {
+	if h.fastOpen {
+		return newPacketConn(&hyFastOpenPacketConn{client: h.client}, h), nil
+	}
 	udpConn, err := h.client.DialUDP()
 	if err != nil {
 		return nil, err
@@ -64,32 +101,69 @@ func (h *Hysteria) ListenPacketContext(ctx context.Context, metadata *C.Metadata
 
 type HysteriaOption struct {
 	BasicOption
-	Name                string   `proxy:"name"`
-	Server              string   `proxy:"server"`
-	Port                int      `proxy:"port"`
-	Protocol            string   `proxy:"protocol,omitempty"`
-	Up                  string   `proxy:"up,omitempty"`
-	UpMbps              int      `proxy:"up_mbps,omitempty"`
-	Down                string   `proxy:"down,omitempty"`
-	DownMbps            int      `proxy:"down_mbps,omitempty"`
-	Auth                string   `proxy:"auth,omitempty"`
-	AuthString          string   `proxy:"auth_str,omitempty"`
-	Obfs                string   `proxy:"obfs,omitempty"`
-	SNI                 string   `proxy:"sni,omitempty"`
-	SkipCertVerify      bool     `proxy:"skip-cert-verify,omitempty"`
-	ALPN                []string `proxy:"alpn,omitempty"`
-	CustomCA            string   `proxy:"ca,omitempty"`
-	CustomCAString      string   `proxy:"ca_str,omitempty"`
-	ReceiveWindowConn   uint64   `proxy:"recv_window_conn,omitempty"`
-	ReceiveWindow       uint64   `proxy:"recv_window,omitempty"`
-	DisableMTUDiscovery bool     `proxy:"disable_mtu_discovery,omitempty"`
-	UDP                 bool     `proxy:"udp,omitempty"`
+	Name                     string   `proxy:"name"`
+	Server                   string   `proxy:"server"`
+	Port                     int      `proxy:"port"`
+	Protocol                 string   `proxy:"protocol,omitempty"`
+	Up                       string   `proxy:"up,omitempty"`
+	UpMbps                   int      `proxy:"up_mbps,omitempty"`
+	UpSpeed                  int      `proxy:"up-speed,omitempty"` // compatible with Stash
+	Down                     string   `proxy:"down,omitempty"`
+	DownMbps                 int      `proxy:"down_mbps,omitempty"`
+	DownSpeed                int      `proxy:"down-speed,omitempty"` // compatible with Stash
+	Auth                     string   `proxy:"auth,omitempty"`
+	AuthString               string   `proxy:"auth_str,omitempty"`
+	AuthStringAlias          string   `proxy:"auth-str,omitempty"` // compatible with Stash
+	Obfs                     string   `proxy:"obfs,omitempty"`
+	ObfsProtocol             string   `proxy:"obfs-protocol,omitempty"` // compatible with Stash
+	SNI                      string   `proxy:"sni,omitempty"`
+	SkipCertVerify           bool     `proxy:"skip-cert-verify,omitempty"`
+	Fingerprint              string   `proxy:"fingerprint,omitempty"`
+	ALPN                     []string `proxy:"alpn,omitempty"`
+	CustomCA                 string   `proxy:"ca,omitempty"`
+	CustomCAString           string   `proxy:"ca_str,omitempty"`
+	ReceiveWindowConn        uint64   `proxy:"recv_window_conn,omitempty"`
+	ReceiveWindowConnAlias   uint64   `proxy:"recv-window-conn,omitempty"` // compatible with Stash
+	ReceiveWindow            uint64   `proxy:"recv_window,omitempty"`
+	ReceiveWindowAlias       uint64   `proxy:"recv-window,omitempty"` // compatible with Stash
+	DisableMTUDiscovery      bool     `proxy:"disable_mtu_discovery,omitempty"`
+	DisableMTUDiscoveryAlias bool     `proxy:"disable-mtu-discovery,omitempty"` // compatible with Stash
+	FastOpen                 bool     `proxy:"fast-open,omitempty"`
+	Congestion               string   `proxy:"congestion,omitempty"`
+	UDP                      bool     `proxy:"udp,omitempty"`
+}
+
+// normalize folds the kebab-case aliases used by Stash/mihomo exports into
+// this struct's canonical (underscore) fields, so a config can use either
+// spelling.
+func (c *HysteriaOption) normalize() {
+	if c.UpMbps == 0 {
+		c.UpMbps = c.UpSpeed
+	}
+	if c.DownMbps == 0 {
+		c.DownMbps = c.DownSpeed
+	}
+	if c.AuthString == "" {
+		c.AuthString = c.AuthStringAlias
+	}
+	if c.Obfs == "" {
+		c.Obfs = c.ObfsProtocol
+	}
+	if c.ReceiveWindowConn == 0 {
+		c.ReceiveWindowConn = c.ReceiveWindowConnAlias
+	}
+	if c.ReceiveWindow == 0 {
+		c.ReceiveWindow = c.ReceiveWindowAlias
+	}
+	if !c.DisableMTUDiscovery {
+		c.DisableMTUDiscovery = c.DisableMTUDiscoveryAlias
+	}
 }
 
 func (c *HysteriaOption) Speed() (uint64, uint64, error) {
 	var up, down uint64
 	if len(c.Up) > 0 {
-		up = stringToBps(c.Up)
+		up = bandwidth.ParseBps(c.Up)
 		if up == 0 {
 			return 0, 0, errors.New("invalid speed format")
 		}
@@ -97,7 +171,7 @@ func (c *HysteriaOption) Speed() (uint64, uint64, error) {
 		up = uint64(c.UpMbps) * mbpsToBps
 	}
 	if len(c.Down) > 0 {
-		down = stringToBps(c.Down)
+		down = bandwidth.ParseBps(c.Down)
 		if down == 0 {
 			return 0, 0, errors.New("invalid speed format")
 		}
@@ -108,6 +182,12 @@ func (c *HysteriaOption) Speed() (uint64, uint64, error) {
 }
 
 func NewHysteria(option HysteriaOption) (*Hysteria, error) {
+	option.normalize()
+
+	if option.Port <= 0 || option.Port > 65535 {
+		return nil, fmt.Errorf("hysteria %s invalid port: %d", option.Server, option.Port)
+	}
+
 	clientTransport := &transport.ClientTransport{
 		Dialer: &net.Dialer{
 			Timeout: 8 * time.Second,
@@ -163,6 +243,15 @@ func NewHysteria(option HysteriaOption) (*Hysteria, error) {
 	if !quicConfig.DisablePathMTUDiscovery && pmtud_fix.DisablePathMTUDiscovery {
 		log.Infoln("hysteria: Path MTU Discovery is not yet supported on this platform")
 	}
+	if option.Fingerprint != "" {
+		// See tlsC.QUICClientHelloSpecGetter: requires go.mod to replace
+		// quic-go with the metacubex fork that actually has this field.
+		getter, err := tlsC.NewQUICClientHelloSpecGetter(option.Fingerprint)
+		if err != nil {
+			return nil, fmt.Errorf("hysteria %s fingerprint error: %w", addr, err)
+		}
+		quicConfig.GetClientHelloSpec = getter
+	}
 	var auth []byte
 	if option.Auth != "" {
 		authBytes, err := base64.StdEncoding.DecodeString(option.Auth)
@@ -177,11 +266,19 @@ func NewHysteria(option HysteriaOption) (*Hysteria, error) {
 	if len(option.Obfs) > 0 {
 		obfuscator = obfs.NewXPlusObfuscator([]byte(option.Obfs))
 	}
-	up, down, _ := option.Speed()
+	up, down, err := option.Speed()
+	if err != nil {
+		return nil, fmt.Errorf("hysteria %s %w", addr, err)
+	}
+	if up == 0 && down == 0 && (option.Congestion == "" || option.Congestion == "brutal") {
+		return nil, fmt.Errorf("hysteria %s up/down must be set when using the brutal congestion controller", addr)
+	}
+	ccFactory, err := newCongestionControlFactory(option.Congestion)
+	if err != nil {
+		return nil, fmt.Errorf("hysteria %s congestion error: %w", addr, err)
+	}
 	client, err := core.NewClient(
-		addr, option.Protocol, auth, tlsConfig, quicConfig, clientTransport, up, down, func(refBPS uint64) congestion.CongestionControl {
-			return hyCongestion.NewBrutalSender(congestion.ByteCount(refBPS))
-		}, obfuscator,
+		addr, option.Protocol, auth, tlsConfig, quicConfig, clientTransport, up, down, ccFactory, obfuscator,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("hysteria %s create error: %w", addr, err)
@@ -197,39 +294,10 @@ func NewHysteria(option HysteriaOption) (*Hysteria, error) {
 		},
 		client:          client,
 		clientTransport: clientTransport,
+		fastOpen:        option.FastOpen,
 	}, nil
 }
 
-func stringToBps(s string) uint64 {
-	if s == "" {
-		return 0
-	}
-	m := rateStringRegexp.FindStringSubmatch(s)
-	if m == nil {
-		return 0
-	}
-	var n uint64
-	switch m[2] {
-	case "K":
-		n = 1 << 10
-	case "M":
-		n = 1 << 20
-	case "G":
-		n = 1 << 30
-	case "T":
-		n = 1 << 40
-	default:
-		n = 1
-	}
-	v, _ := strconv.ParseUint(m[1], 10, 64)
-	n = v * n
-	if m[3] == "b" {
-		// Bits, need to convert to bytes
-		n = n >> 3
-	}
-	return n
-}
-
 type hyPacketConn struct {
 	core.UDPConn
 }
@@ -251,4 +319,201 @@ func (c *hyPacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
 	}
 	n = len(p)
 	return
-}
\ No newline at end of file
+}
+
+// hyFastOpenConn defers the Hysteria TCP-request round trip until the first
+// Write, piggybacking the caller's initial payload onto the request frame
+// instead of waiting an extra RTT for a bare handshake to finish. The first
+// Read blocks until the server's response header arrives, same as a normal
+// DialTCP.
+//
+// hyTCPDialer is the subset of *core.Client that hyFastOpenConn needs,
+// narrowed out so tests can exercise the dial-once race without a real
+// Hysteria server. Stock github.com/tobyxdd/hysteria's core.Client has no
+// DialTCPWithPayload method - like tlsC.QUICClientHelloSpecGetter in
+// component/tls/utls.go, this only compiles against a patched fork that
+// adds a "prepare stream / commit with data" variant of DialTCP. go.mod
+// must carry
+//
+//	replace github.com/tobyxdd/hysteria => <fork with DialTCPWithPayload>
+//
+// Without that replace directive, *core.Client does not satisfy hyTCPDialer
+// and fast-open fails to compile.
+type hyTCPDialer interface {
+	DialTCPWithPayload(addr string, payload []byte) (net.Conn, error)
+}
+
+type hyFastOpenConn struct {
+	client hyTCPDialer
+	addr   string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// open lazily dials the TCP request stream, piggybacking initialData onto
+// the request frame if this call is the one that performs the dial.
+// consumed reports whether initialData was sent this way, so a caller that
+// passed a real payload knows whether it still needs to Write it itself.
+func (c *hyFastOpenConn) open(initialData []byte) (conn net.Conn, consumed bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		return c.conn, false, nil
+	}
+	conn, err = c.client.DialTCPWithPayload(c.addr, initialData)
+	if err != nil {
+		return nil, false, err
+	}
+	c.conn = conn
+	return conn, true, nil
+}
+
+func (c *hyFastOpenConn) Read(b []byte) (int, error) {
+	conn, _, err := c.open(nil)
+	if err != nil {
+		return 0, err
+	}
+	return conn.Read(b)
+}
+
+func (c *hyFastOpenConn) Write(b []byte) (int, error) {
+	// Route through open so the first Read and the first Write - which clash
+	// starts concurrently in separate relay goroutines right after
+	// DialContext returns - serialize on c.mu instead of each dialing their
+	// own stream and racing to decide which one c.conn ends up being.
+	conn, consumed, err := c.open(b)
+	if err != nil {
+		return 0, err
+	}
+	if consumed {
+		return len(b), nil
+	}
+	return conn.Write(b)
+}
+
+func (c *hyFastOpenConn) Close() error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
+
+func (c *hyFastOpenConn) LocalAddr() net.Addr {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return conn.LocalAddr()
+}
+
+func (c *hyFastOpenConn) RemoteAddr() net.Addr {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return conn.RemoteAddr()
+}
+
+func (c *hyFastOpenConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return conn.SetDeadline(t)
+}
+
+func (c *hyFastOpenConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return conn.SetReadDeadline(t)
+}
+
+func (c *hyFastOpenConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return conn.SetWriteDeadline(t)
+}
+
+// hyFastOpenPacketConn defers the synchronous UDP session-establishment
+// round trip from ListenPacketContext until the first ReadFrom/WriteTo,
+// so a caller that never sends UDP never pays for the session at all.
+//
+// Unlike the TCP side, this does not inline the round trip onto the first
+// datagram: Hysteria's UDP session protocol has no equivalent of
+// DialTCPWithPayload to piggyback a payload onto the session-establishment
+// message, so the first ReadFrom/WriteTo still blocks on the full dial
+// before it can proceed.
+type hyFastOpenPacketConn struct {
+	client *core.Client
+
+	mu   sync.Mutex
+	conn core.UDPConn
+}
+
+func (c *hyFastOpenPacketConn) open() (core.UDPConn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		return c.conn, nil
+	}
+	conn, err := c.client.DialUDP()
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+func (c *hyFastOpenPacketConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	conn, err := c.open()
+	if err != nil {
+		return 0, nil, err
+	}
+	b, addrStr, err := conn.ReadFrom()
+	if err != nil {
+		return
+	}
+	n = copy(p, b)
+	addr = M.ParseSocksaddr(addrStr).UDPAddr()
+	return
+}
+
+func (c *hyFastOpenPacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	conn, err := c.open()
+	if err != nil {
+		return 0, err
+	}
+	if err = conn.WriteTo(p, M.SocksaddrFromNet(addr).String()); err != nil {
+		return
+	}
+	n = len(p)
+	return
+}
+
+func (c *hyFastOpenPacketConn) Close() error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
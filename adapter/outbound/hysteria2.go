@@ -0,0 +1,515 @@
+package outbound
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dreamacro/clash/component/bandwidth"
+	"github.com/Dreamacro/clash/component/dialer"
+	"github.com/Dreamacro/clash/component/obfs"
+	tlsC "github.com/Dreamacro/clash/component/tls"
+	C "github.com/Dreamacro/clash/constant"
+	"github.com/lucas-clemente/quic-go"
+)
+
+const (
+	hy2StatusOK = 0x00
+
+	hy2DefaultALPN = "h3"
+)
+
+type Hysteria2 struct {
+	*Base
+
+	option *Hysteria2Option
+
+	connMutex sync.Mutex
+	conn      quic.Connection
+	demux     *hy2Demux
+}
+
+type Hysteria2Option struct {
+	BasicOption
+	Name           string   `proxy:"name"`
+	Server         string   `proxy:"server"`
+	Port           int      `proxy:"port,omitempty"`
+	PortRange      string   `proxy:"port-range,omitempty"`
+	Password       string   `proxy:"password"`
+	Obfs           string   `proxy:"obfs,omitempty"`
+	ObfsPassword   string   `proxy:"obfs-password,omitempty"`
+	Up             string   `proxy:"up,omitempty"`
+	Down           string   `proxy:"down,omitempty"`
+	SNI            string   `proxy:"sni,omitempty"`
+	SkipCertVerify bool     `proxy:"skip-cert-verify,omitempty"`
+	Fingerprint    string   `proxy:"fingerprint,omitempty"`
+	ALPN           []string `proxy:"alpn,omitempty"`
+	CustomCA       string   `proxy:"ca,omitempty"`
+	CustomCAString string   `proxy:"ca_str,omitempty"`
+	UDP            bool     `proxy:"udp,omitempty"`
+}
+
+// pickPort picks the port to dial: a random one out of PortRange (for port
+// hopping) when set, otherwise the fixed Port.
+func (o *Hysteria2Option) pickPort() (int, error) {
+	if o.PortRange == "" {
+		if o.Port <= 0 || o.Port > 65535 {
+			return 0, fmt.Errorf("invalid port: %d", o.Port)
+		}
+		return o.Port, nil
+	}
+	parts := strings.SplitN(o.PortRange, "-", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid port-range: %s", o.PortRange)
+	}
+	lo, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, fmt.Errorf("invalid port-range: %s", o.PortRange)
+	}
+	hi, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, fmt.Errorf("invalid port-range: %s", o.PortRange)
+	}
+	if lo <= 0 || hi > 65535 || lo > hi {
+		return 0, fmt.Errorf("invalid port-range: %s", o.PortRange)
+	}
+	return lo + int(timeSeed()%uint64(hi-lo+1)), nil
+}
+
+func timeSeed() uint64 {
+	return uint64(time.Now().UnixNano())
+}
+
+// speed parses Up/Down into bytes/sec, both optional: a zero value tells the
+// server to let BBR discover the rate on its own instead of trusting a hint.
+func (o *Hysteria2Option) speed() (uint64, uint64, error) {
+	var up, down uint64
+	if o.Up != "" {
+		up = bandwidth.ParseBps(o.Up)
+		if up == 0 {
+			return 0, 0, errors.New("invalid speed format")
+		}
+	}
+	if o.Down != "" {
+		down = bandwidth.ParseBps(o.Down)
+		if down == 0 {
+			return 0, 0, errors.New("invalid speed format")
+		}
+	}
+	return up, down, nil
+}
+
+func (h *Hysteria2) dial(ctx context.Context) (quic.Connection, error) {
+	h.connMutex.Lock()
+	defer h.connMutex.Unlock()
+	if h.conn != nil {
+		select {
+		case <-h.conn.Context().Done():
+		default:
+			return h.conn, nil
+		}
+	}
+
+	option := h.option
+	port, err := option.pickPort()
+	if err != nil {
+		return nil, err
+	}
+	addr := net.JoinHostPort(option.Server, strconv.Itoa(port))
+
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("hysteria2 %s resolve error: %w", addr, err)
+	}
+	pktConn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, fmt.Errorf("hysteria2 %s listen error: %w", addr, err)
+	}
+
+	var packetConn net.PacketConn = pktConn
+	if option.Obfs == "salamander" {
+		packetConn = &salamanderPacketConn{PacketConn: pktConn, obfs: obfs.NewSalamander(option.ObfsPassword)}
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         option.SNI,
+		InsecureSkipVerify: option.SkipCertVerify,
+		MinVersion:         tls.VersionTLS13,
+	}
+	if len(option.ALPN) > 0 {
+		tlsConfig.NextProtos = option.ALPN
+	} else {
+		tlsConfig.NextProtos = []string{hy2DefaultALPN}
+	}
+	if len(option.CustomCA) > 0 {
+		bs, err := ioutil.ReadFile(option.CustomCA)
+		if err != nil {
+			return nil, fmt.Errorf("hysteria2 %s load ca error: %w", addr, err)
+		}
+		cp := x509.NewCertPool()
+		if !cp.AppendCertsFromPEM(bs) {
+			return nil, fmt.Errorf("hysteria2 %s failed to parse ca", addr)
+		}
+		tlsConfig.RootCAs = cp
+	} else if option.CustomCAString != "" {
+		cp := x509.NewCertPool()
+		if !cp.AppendCertsFromPEM([]byte(option.CustomCAString)) {
+			return nil, fmt.Errorf("hysteria2 %s failed to parse ca_str", addr)
+		}
+		tlsConfig.RootCAs = cp
+	}
+	quicConfig := &quic.Config{
+		KeepAlive:       true,
+		EnableDatagrams: true,
+	}
+	if option.Fingerprint != "" {
+		// See tlsC.QUICClientHelloSpecGetter: requires go.mod to replace
+		// quic-go with the metacubex fork that actually has this field.
+		getter, err := tlsC.NewQUICClientHelloSpecGetter(option.Fingerprint)
+		if err != nil {
+			return nil, fmt.Errorf("hysteria2 %s fingerprint error: %w", addr, err)
+		}
+		quicConfig.GetClientHelloSpec = getter
+	}
+
+	conn, err := quic.DialContext(ctx, packetConn, udpAddr, addr, tlsConfig, quicConfig)
+	if err != nil {
+		return nil, fmt.Errorf("hysteria2 %s dial error: %w", addr, err)
+	}
+	if err := h.authenticate(ctx, conn); err != nil {
+		_ = conn.CloseWithError(0, "")
+		return nil, fmt.Errorf("hysteria2 %s auth error: %w", addr, err)
+	}
+	h.conn = conn
+	h.demux = newHy2Demux(conn)
+	return conn, nil
+}
+
+// authenticate runs the HTTP/3-style password handshake on a dedicated
+// control stream: the client sends its password plus its up/down rate hint,
+// the server replies with a status byte. Only that status byte is read; any
+// rate the server sends after it is neither read nor used.
+func (h *Hysteria2) authenticate(ctx context.Context, conn quic.Connection) error {
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	pw := []byte(h.option.Password)
+	if len(pw) > 0xffff {
+		return fmt.Errorf("password too long: %d bytes", len(pw))
+	}
+	buf := make([]byte, 2+len(pw))
+	binary.BigEndian.PutUint16(buf, uint16(len(pw)))
+	copy(buf[2:], pw)
+	if _, err := stream.Write(buf); err != nil {
+		return err
+	}
+
+	up, down, err := h.option.speed()
+	if err != nil {
+		return err
+	}
+	var rateBuf [16]byte
+	binary.BigEndian.PutUint64(rateBuf[:8], up)
+	binary.BigEndian.PutUint64(rateBuf[8:], down)
+	if _, err := stream.Write(rateBuf[:]); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(stream)
+	status, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if status != hy2StatusOK {
+		return errors.New("server rejected authentication")
+	}
+	return nil
+}
+
+func (h *Hysteria2) DialContext(ctx context.Context, metadata *C.Metadata, opts ...dialer.Option) (C.Conn, error) {
+	conn, err := h.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeTCPRequest(stream, metadata.RemoteAddress()); err != nil {
+		_ = stream.Close()
+		return nil, err
+	}
+	if err := readTCPResponse(stream); err != nil {
+		_ = stream.Close()
+		return nil, err
+	}
+
+	return NewConn(&quicStreamConn{Stream: stream, conn: conn}, h), nil
+}
+
+func writeTCPRequest(stream quic.Stream, addr string) error {
+	buf := make([]byte, 0, 4+len(addr))
+	var varint [binary.MaxVarintLen64]byte
+	vn := binary.PutUvarint(varint[:], uint64(len(addr)))
+	buf = append(buf, varint[:vn]...)
+	buf = append(buf, addr...)
+	_, err := stream.Write(buf)
+	return err
+}
+
+func readTCPResponse(stream quic.Stream) error {
+	r := bufio.NewReader(stream)
+	status, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if status != hy2StatusOK {
+		return errors.New("server rejected TCP request")
+	}
+	return nil
+}
+
+func (h *Hysteria2) ListenPacketContext(ctx context.Context, metadata *C.Metadata, opts ...dialer.Option) (C.PacketConn, error) {
+	conn, err := h.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	h.connMutex.Lock()
+	demux := h.demux
+	h.connMutex.Unlock()
+	udpConn := newHy2UDPConn(conn, demux)
+	if err := udpConn.associate(ctx); err != nil {
+		return nil, fmt.Errorf("hysteria2 %s udp associate error: %w", h.addr, err)
+	}
+	return newPacketConn(udpConn, h), nil
+}
+
+func NewHysteria2(option Hysteria2Option) (*Hysteria2, error) {
+	if option.Password == "" {
+		return nil, errors.New("hysteria2: password is required")
+	}
+	port, err := option.pickPort()
+	if err != nil {
+		return nil, err
+	}
+	addr := net.JoinHostPort(option.Server, strconv.Itoa(port))
+	if option.Obfs != "" && option.Obfs != "salamander" {
+		return nil, fmt.Errorf("hysteria2 %s unsupported obfs: %s", addr, option.Obfs)
+	}
+
+	opt := option
+	// C.Hysteria2 is a new AdapterType this protocol introduces; it belongs
+	// in constant/adapters.go next to C.Hysteria, and NewHysteria2 belongs in
+	// the outbound parser's proxy["type"] switch - neither file is part of
+	// this checkout (NewHysteria itself isn't wired into any such switch
+	// here either), so there's nothing in this tree left to change.
+	return &Hysteria2{
+		Base: &Base{
+			name:  option.Name,
+			addr:  addr,
+			tp:    C.Hysteria2,
+			udp:   option.UDP,
+			iface: option.Interface,
+			rmark: option.RoutingMark,
+		},
+		option: &opt,
+	}, nil
+}
+
+type salamanderPacketConn struct {
+	net.PacketConn
+	obfs *obfs.Salamander
+}
+
+func (c *salamanderPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	buf := make([]byte, len(p)+8)
+	n, addr, err := c.PacketConn.ReadFrom(buf)
+	if err != nil {
+		return 0, addr, err
+	}
+	n = c.obfs.Deobfuscate(buf[:n], p)
+	return n, addr, nil
+}
+
+func (c *salamanderPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	buf := make([]byte, len(p)+8)
+	n, err := c.obfs.Obfuscate(p, buf)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := c.PacketConn.WriteTo(buf[:n], addr); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// quicStreamConn adapts a quic.Stream to net.Conn for use as a TCP proxy
+// connection.
+type quicStreamConn struct {
+	quic.Stream
+	conn quic.Connection
+}
+
+func (c *quicStreamConn) LocalAddr() net.Addr  { return c.conn.LocalAddr() }
+func (c *quicStreamConn) RemoteAddr() net.Addr { return c.conn.RemoteAddr() }
+
+var _ net.Conn = (*quicStreamConn)(nil)
+
+// hy2FrameUDPAssociate is the full frame-type value, sent varint-encoded
+// the same way writeTCPRequest length-prefixes its address - not a single
+// byte, so it must never be masked down with &0xff before being written.
+const hy2FrameUDPAssociate = 0x403
+
+// hy2Demux runs the single reader goroutine a shared quic.Connection's
+// datagrams require: only one goroutine may call ReceiveMessage on a given
+// connection at a time, so every hy2UDPConn sharing that connection
+// registers its session ID here instead of calling ReceiveMessage itself,
+// and gets its datagrams redispatched onto a private channel.
+type hy2Demux struct {
+	conn quic.Connection
+
+	mu       sync.Mutex
+	sessions map[uint32]chan []byte
+}
+
+func newHy2Demux(conn quic.Connection) *hy2Demux {
+	d := &hy2Demux{conn: conn, sessions: make(map[uint32]chan []byte)}
+	go d.readLoop()
+	return d
+}
+
+func (d *hy2Demux) readLoop() {
+	for {
+		msg, err := d.conn.ReceiveMessage(context.Background())
+		if err != nil {
+			d.closeAll()
+			return
+		}
+		if len(msg) < 4 {
+			continue
+		}
+		sessionID := binary.BigEndian.Uint32(msg[:4])
+		d.mu.Lock()
+		ch, ok := d.sessions[sessionID]
+		d.mu.Unlock()
+		if !ok {
+			// No session registered for this ID (already closed, or a
+			// datagram that raced ahead of associate()'s reply): drop it.
+			continue
+		}
+		select {
+		case ch <- msg[4:]:
+		default:
+			// Slow consumer: drop rather than block the shared reader and
+			// stall every other session on this connection.
+		}
+	}
+}
+
+// register allocates the channel a session's ReadFrom receives datagrams on.
+func (d *hy2Demux) register(sessionID uint32) <-chan []byte {
+	ch := make(chan []byte, 64)
+	d.mu.Lock()
+	d.sessions[sessionID] = ch
+	d.mu.Unlock()
+	return ch
+}
+
+func (d *hy2Demux) unregister(sessionID uint32) {
+	d.mu.Lock()
+	delete(d.sessions, sessionID)
+	d.mu.Unlock()
+}
+
+func (d *hy2Demux) closeAll() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for id, ch := range d.sessions {
+		close(ch)
+		delete(d.sessions, id)
+	}
+}
+
+// hy2UDPConn multiplexes a single UDP session over the shared QUIC
+// connection's unreliable datagrams, tagged with a session ID assigned by
+// the server. Reads come from its own channel on the connection's demux
+// rather than calling ReceiveMessage directly, so concurrent sessions don't
+// race over - and drop - each other's datagrams.
+type hy2UDPConn struct {
+	conn      quic.Connection
+	demux     *hy2Demux
+	sessionID uint32
+	recv      <-chan []byte
+}
+
+func newHy2UDPConn(conn quic.Connection, demux *hy2Demux) *hy2UDPConn {
+	return &hy2UDPConn{conn: conn, demux: demux}
+}
+
+func (c *hy2UDPConn) associate(ctx context.Context) error {
+	stream, err := c.conn.OpenStreamSync(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	var frameType [binary.MaxVarintLen64]byte
+	fn := binary.PutUvarint(frameType[:], hy2FrameUDPAssociate)
+	if _, err := stream.Write(frameType[:fn]); err != nil {
+		return err
+	}
+	r := bufio.NewReader(stream)
+	status, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if status != hy2StatusOK {
+		return errors.New("server rejected UDP associate")
+	}
+	var idBuf [4]byte
+	if _, err := io.ReadFull(r, idBuf[:]); err != nil {
+		return err
+	}
+	c.sessionID = binary.BigEndian.Uint32(idBuf[:])
+	c.recv = c.demux.register(c.sessionID)
+	return nil
+}
+
+func (c *hy2UDPConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	msg, ok := <-c.recv
+	if !ok {
+		return 0, nil, net.ErrClosed
+	}
+	n = copy(p, msg)
+	return n, c.conn.RemoteAddr(), nil
+}
+
+func (c *hy2UDPConn) WriteTo(p []byte, _ net.Addr) (int, error) {
+	msg := make([]byte, 4+len(p))
+	binary.BigEndian.PutUint32(msg, c.sessionID)
+	copy(msg[4:], p)
+	if err := c.conn.SendMessage(msg); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *hy2UDPConn) Close() error {
+	c.demux.unregister(c.sessionID)
+	return nil
+}
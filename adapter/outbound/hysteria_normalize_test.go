@@ -0,0 +1,55 @@
+package outbound
+
+import "testing"
+
+func TestHysteriaOptionNormalizePrefersCanonicalOverAlias(t *testing.T) {
+	opt := HysteriaOption{
+		UpMbps:          10,
+		UpSpeed:         20,
+		AuthString:      "canonical",
+		AuthStringAlias: "alias",
+	}
+	opt.normalize()
+
+	if opt.UpMbps != 10 {
+		t.Fatalf("expected canonical up_mbps to win, got %d", opt.UpMbps)
+	}
+	if opt.AuthString != "canonical" {
+		t.Fatalf("expected canonical auth_str to win, got %q", opt.AuthString)
+	}
+}
+
+func TestHysteriaOptionNormalizeFallsBackToAlias(t *testing.T) {
+	opt := HysteriaOption{
+		UpSpeed:                  20,
+		DownSpeed:                30,
+		AuthStringAlias:          "alias-auth",
+		ObfsProtocol:             "alias-obfs",
+		ReceiveWindowConnAlias:   1024,
+		ReceiveWindowAlias:       2048,
+		DisableMTUDiscoveryAlias: true,
+	}
+	opt.normalize()
+
+	if opt.UpMbps != 20 {
+		t.Fatalf("expected up_mbps to fall back to up-speed, got %d", opt.UpMbps)
+	}
+	if opt.DownMbps != 30 {
+		t.Fatalf("expected down_mbps to fall back to down-speed, got %d", opt.DownMbps)
+	}
+	if opt.AuthString != "alias-auth" {
+		t.Fatalf("expected auth_str to fall back to auth-str, got %q", opt.AuthString)
+	}
+	if opt.Obfs != "alias-obfs" {
+		t.Fatalf("expected obfs to fall back to obfs-protocol, got %q", opt.Obfs)
+	}
+	if opt.ReceiveWindowConn != 1024 {
+		t.Fatalf("expected recv_window_conn to fall back to recv-window-conn, got %d", opt.ReceiveWindowConn)
+	}
+	if opt.ReceiveWindow != 2048 {
+		t.Fatalf("expected recv_window to fall back to recv-window, got %d", opt.ReceiveWindow)
+	}
+	if !opt.DisableMTUDiscovery {
+		t.Fatalf("expected disable_mtu_discovery to fall back to disable-mtu-discovery")
+	}
+}
@@ -0,0 +1,79 @@
+package outbound
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeTCPDialer is a hyTCPDialer stub that records how many times
+// DialTCPWithPayload was called and what payload it saw.
+type fakeTCPDialer struct {
+	dials   int32
+	payload []byte
+}
+
+func (d *fakeTCPDialer) DialTCPWithPayload(_ string, payload []byte) (net.Conn, error) {
+	atomic.AddInt32(&d.dials, 1)
+	d.payload = payload
+	server, client := net.Pipe()
+	go func() {
+		// Reply unconditionally instead of waiting for a client write: when
+		// Write's own dial wins the race it never writes through conn (its
+		// payload already rode the dial itself), so a server that only
+		// replies after reading would block forever and hang the Read side.
+		server.Write([]byte("ok"))
+		server.Close()
+	}()
+	return client, nil
+}
+
+// TestHyFastOpenConnSerializesFirstDial guards against the race where a
+// concurrent first Read and first Write each observe c.conn == nil and dial
+// their own stream: only one DialTCPWithPayload call should ever happen.
+// Which of Read's open(nil) or Write's open(payload) wins that race is
+// unspecified - either is a correct outcome (TestHyFastOpenConnWriteDoesNotResendConsumedPayload
+// deterministically covers the payload-carries-through case) - so this test
+// only asserts the dial is deduplicated, not which side's payload it saw.
+func TestHyFastOpenConnSerializesFirstDial(t *testing.T) {
+	dialer := &fakeTCPDialer{}
+	c := &hyFastOpenConn{client: dialer, addr: "example.com:443"}
+
+	payload := []byte("hello")
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, _ = c.Write(payload)
+	}()
+	go func() {
+		defer wg.Done()
+		buf := make([]byte, 16)
+		_, _ = c.Read(buf)
+	}()
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&dialer.dials); got != 1 {
+		t.Fatalf("expected exactly 1 dial, got %d", got)
+	}
+}
+
+func TestHyFastOpenConnWriteDoesNotResendConsumedPayload(t *testing.T) {
+	dialer := &fakeTCPDialer{}
+	c := &hyFastOpenConn{client: dialer, addr: "example.com:443"}
+
+	n, err := c.Write([]byte("abc"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 bytes written, got %d", n)
+	}
+	if got := atomic.LoadInt32(&dialer.dials); got != 1 {
+		t.Fatalf("expected exactly 1 dial, got %d", got)
+	}
+	if string(dialer.payload) != "abc" {
+		t.Fatalf("expected the payload to ride the dial, got %q", dialer.payload)
+	}
+}
@@ -0,0 +1,43 @@
+// Package bandwidth parses the human-readable rate strings ("100 Mbps",
+// "12MBps") Hysteria's inbound and outbound both accept for up/down, so
+// they share one implementation instead of each carrying their own copy.
+package bandwidth
+
+import (
+	"regexp"
+	"strconv"
+)
+
+var rateStringRegexp = regexp.MustCompile(`^(\d+)\s*([KMGT]?)([Bb])ps$`)
+
+// ParseBps parses s into bytes/sec. It returns 0 if s is empty or doesn't
+// match the expected format.
+func ParseBps(s string) uint64 {
+	if s == "" {
+		return 0
+	}
+	m := rateStringRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return 0
+	}
+	var n uint64
+	switch m[2] {
+	case "K":
+		n = 1 << 10
+	case "M":
+		n = 1 << 20
+	case "G":
+		n = 1 << 30
+	case "T":
+		n = 1 << 40
+	default:
+		n = 1
+	}
+	v, _ := strconv.ParseUint(m[1], 10, 64)
+	n = v * n
+	if m[3] == "b" {
+		// Bits, need to convert to bytes
+		n >>= 3
+	}
+	return n
+}
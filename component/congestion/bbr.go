@@ -0,0 +1,271 @@
+// Package congestion implements a BBRv2-style congestion controller for use
+// with quic-go's pluggable congestion.CongestionControl interface, as an
+// alternative to Hysteria's default Brutal sender for links whose capacity
+// the user hasn't measured ahead of time.
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/congestion"
+)
+
+type bbrState int
+
+const (
+	bbrStateStartup bbrState = iota
+	bbrStateDrain
+	bbrStateProbeBW
+	bbrStateProbeRTT
+)
+
+const (
+	bbrStartupGain   = 2.885 // 2/ln(2), to double the delivery rate each round
+	bbrDrainGain     = 1 / bbrStartupGain
+	probeRTTInterval = 10 * time.Second
+	probeRTTDuration = 200 * time.Millisecond
+	btlBwWindow      = 10 // rounds
+	rtPropWindow     = 10 * time.Second
+)
+
+var probeBWGainCycle = [8]float64{1.25, 0.75, 1, 1, 1, 1, 1, 1}
+
+// Sender is a BBRv2 congestion.CongestionControl implementation: it tracks
+// BtlBw as a windowed-max of delivery rate and RTprop as a windowed-min of
+// RTT, then derives cwnd and pacing rate from the two instead of reacting to
+// loss the way Cubic/Reno do.
+type Sender struct {
+	maxDatagramSize congestion.ByteCount
+
+	state bbrState
+
+	btlBw       congestion.ByteCount // bytes/sec, windowed max delivery rate
+	btlBwRound  int
+	btlBwFilter [btlBwWindow]congestion.ByteCount
+
+	rtProp      time.Duration
+	rtPropStamp time.Time
+
+	pacingGain float64
+	cwndGain   float64
+	cycleIndex int
+	cycleStamp time.Time
+
+	probeRTTDone      time.Time
+	probeRTTRoundDone bool
+
+	bytesInFlight congestion.ByteCount
+	lastSentTime  time.Time
+	sentAt        map[congestion.PacketNumber]time.Time
+
+	roundStart         time.Time
+	deliveredBytes     congestion.ByteCount
+	deliveredTime      time.Time
+	startupBtlBw       congestion.ByteCount
+	startupStallRounds int
+}
+
+var _ congestion.CongestionControl = &Sender{}
+
+// NewSender constructs a BBRv2 sender. It takes no up/down bandwidth hint:
+// BBR discovers bandwidth on its own.
+//
+// deliveredTime is left zero rather than seeded from time.Now(): the sender
+// is driven by whatever clock OnPacketSent/OnPacketAcked are called with
+// (congestion.DefaultClock in production, a synthetic clock in tests), and
+// that clock has no obligation to agree with wall-clock time at
+// construction. updateDeliveryRate anchors deliveredTime from the first
+// real sample instead.
+func NewSender() *Sender {
+	return &Sender{
+		maxDatagramSize: 1252,
+		state:           bbrStateStartup,
+		pacingGain:      bbrStartupGain,
+		cwndGain:        bbrStartupGain,
+		rtProp:          time.Hour, // unset sentinel, replaced by the first sample
+		sentAt:          make(map[congestion.PacketNumber]time.Time),
+	}
+}
+
+func (b *Sender) SetMaxDatagramSize(size congestion.ByteCount) {
+	b.maxDatagramSize = size
+}
+
+func (b *Sender) TimeUntilSend(bytesInFlight congestion.ByteCount) time.Time {
+	if b.HasPacingBudget() {
+		return time.Time{}
+	}
+	rate := b.pacingRate()
+	if rate <= 0 {
+		return time.Time{}
+	}
+	interval := time.Duration(float64(b.maxDatagramSize) / rate * float64(time.Second))
+	return b.lastSentTime.Add(interval)
+}
+
+func (b *Sender) HasPacingBudget() bool {
+	return b.bytesInFlight < b.cwnd()
+}
+
+func (b *Sender) CanSend(bytesInFlight congestion.ByteCount) bool {
+	return bytesInFlight < b.cwnd()
+}
+
+func (b *Sender) MaybeExitSlowStart() {}
+
+func (b *Sender) OnPacketSent(sentTime time.Time, bytesInFlight congestion.ByteCount, number congestion.PacketNumber, bytes congestion.ByteCount, isRetransmittable bool) {
+	b.lastSentTime = sentTime
+	if isRetransmittable {
+		b.bytesInFlight = bytesInFlight + bytes
+		b.sentAt[number] = sentTime
+	}
+}
+
+func (b *Sender) OnPacketAcked(number congestion.PacketNumber, ackedBytes congestion.ByteCount, priorInFlight congestion.ByteCount, eventTime time.Time) {
+	if b.bytesInFlight >= ackedBytes {
+		b.bytesInFlight -= ackedBytes
+	} else {
+		b.bytesInFlight = 0
+	}
+	if sentTime, ok := b.sentAt[number]; ok {
+		delete(b.sentAt, number)
+		b.updateRTProp(eventTime.Sub(sentTime), eventTime)
+	}
+	b.updateDeliveryRate(ackedBytes, eventTime)
+	b.updateState(eventTime)
+}
+
+func (b *Sender) OnPacketLost(number congestion.PacketNumber, lostBytes congestion.ByteCount, _ congestion.ByteCount) {
+	delete(b.sentAt, number)
+	if b.bytesInFlight >= lostBytes {
+		b.bytesInFlight -= lostBytes
+	} else {
+		b.bytesInFlight = 0
+	}
+}
+
+func (b *Sender) OnRetransmissionTimeout(packetsRetransmitted bool) {}
+
+// updateDeliveryRate folds a newly-acked chunk into the current round's
+// delivery rate and, once a round completes, into the BtlBw windowed-max
+// filter.
+func (b *Sender) updateDeliveryRate(ackedBytes congestion.ByteCount, now time.Time) {
+	if b.deliveredTime.IsZero() {
+		// First sample: anchor the baseline here instead of at
+		// construction, so elapsed is measured against the caller's clock
+		// rather than whatever time.Now() was when NewSender ran.
+		b.deliveredTime = now
+		return
+	}
+	b.deliveredBytes += ackedBytes
+	elapsed := now.Sub(b.deliveredTime)
+	if elapsed <= 0 {
+		return
+	}
+	rate := congestion.ByteCount(float64(b.deliveredBytes) / elapsed.Seconds())
+
+	if b.roundStart.IsZero() || now.Sub(b.roundStart) >= b.rtProp {
+		b.btlBwFilter[b.btlBwRound%btlBwWindow] = rate
+		b.btlBwRound++
+		b.roundStart = now
+		b.deliveredBytes = 0
+		b.deliveredTime = now
+
+		var max congestion.ByteCount
+		n := b.btlBwRound
+		if n > btlBwWindow {
+			n = btlBwWindow
+		}
+		for i := 0; i < n; i++ {
+			if b.btlBwFilter[i] > max {
+				max = b.btlBwFilter[i]
+			}
+		}
+		b.btlBw = max
+
+		if b.state == bbrStateStartup {
+			// >25% BtlBw growth resets the stall counter; STARTUP ends once
+			// it's stalled for 3 consecutive rounds.
+			if b.btlBw > b.startupBtlBw+b.startupBtlBw/4 {
+				b.startupStallRounds = 0
+			} else {
+				b.startupStallRounds++
+			}
+			if b.btlBw > b.startupBtlBw {
+				b.startupBtlBw = b.btlBw
+			}
+		}
+	}
+}
+
+// updateRTProp folds an actual RTT sample (sentTime-to-ack, not just the
+// inter-ack gap) into the windowed-min RTprop filter.
+func (b *Sender) updateRTProp(rtt time.Duration, now time.Time) {
+	if rtt <= 0 {
+		return
+	}
+	if rtt < b.rtProp || now.Sub(b.rtPropStamp) > rtPropWindow {
+		b.rtProp = rtt
+		b.rtPropStamp = now
+	}
+}
+
+func (b *Sender) updateState(now time.Time) {
+	switch b.state {
+	case bbrStateStartup:
+		// 3 rounds without BtlBw growing by >25% ends STARTUP.
+		if b.startupStallRounds >= 3 {
+			b.state = bbrStateDrain
+			b.pacingGain = bbrDrainGain
+			b.cwndGain = bbrStartupGain
+		}
+	case bbrStateDrain:
+		if b.bytesInFlight <= b.cwnd() {
+			b.state = bbrStateProbeBW
+			b.cycleIndex = 0
+			b.cycleStamp = now
+			b.pacingGain = probeBWGainCycle[0]
+			b.cwndGain = 2
+		}
+	case bbrStateProbeBW:
+		if now.Sub(b.cycleStamp) >= b.rtProp {
+			b.cycleIndex = (b.cycleIndex + 1) % len(probeBWGainCycle)
+			b.cycleStamp = now
+			b.pacingGain = probeBWGainCycle[b.cycleIndex]
+		}
+		if now.Sub(b.rtPropStamp) > probeRTTInterval {
+			b.state = bbrStateProbeRTT
+			b.pacingGain = 1
+			b.cwndGain = 1
+			b.probeRTTDone = now.Add(probeRTTDuration)
+		}
+	case bbrStateProbeRTT:
+		if now.After(b.probeRTTDone) {
+			b.state = bbrStateProbeBW
+			b.cycleIndex = 0
+			b.cycleStamp = now
+			b.pacingGain = probeBWGainCycle[0]
+			b.cwndGain = 2
+			b.rtPropStamp = now
+		}
+	}
+}
+
+func (b *Sender) pacingRate() float64 {
+	if b.btlBw == 0 {
+		return 0
+	}
+	return b.pacingGain * float64(b.btlBw)
+}
+
+func (b *Sender) cwnd() congestion.ByteCount {
+	if b.btlBw == 0 || b.rtProp <= 0 || b.rtProp == time.Hour {
+		// No estimate yet: behave like slow-start with a small fixed window.
+		return 4 * b.maxDatagramSize
+	}
+	bdp := congestion.ByteCount(b.cwndGain * float64(b.btlBw) * b.rtProp.Seconds())
+	if bdp < 4*b.maxDatagramSize {
+		bdp = 4 * b.maxDatagramSize
+	}
+	return bdp
+}
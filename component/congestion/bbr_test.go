@@ -0,0 +1,53 @@
+package congestion
+
+import (
+	"testing"
+	"time"
+
+	quicCongestion "github.com/lucas-clemente/quic-go/congestion"
+)
+
+// TestSenderStartupExitsOnStall drives a Sender through acks whose delivery
+// rate stops growing and checks it leaves STARTUP for DRAIN, rather than
+// exiting after a fixed number of rounds regardless of growth.
+func TestSenderStartupExitsOnStall(t *testing.T) {
+	s := NewSender()
+	now := time.Unix(0, 0)
+	num := quicCongestion.PacketNumber(0)
+
+	send := func(bytes quicCongestion.ByteCount) quicCongestion.PacketNumber {
+		num++
+		s.OnPacketSent(now, s.bytesInFlight, num, bytes, true)
+		return num
+	}
+	ack := func(n quicCongestion.PacketNumber, bytes quicCongestion.ByteCount, rtt time.Duration) {
+		now = now.Add(rtt)
+		s.OnPacketAcked(n, bytes, s.bytesInFlight, now)
+	}
+
+	// A handful of rounds at a flat delivery rate should eventually stall
+	// STARTUP and move to DRAIN.
+	for i := 0; i < 10 && s.state == bbrStateStartup; i++ {
+		n := send(1000)
+		ack(n, 1000, 10*time.Millisecond)
+	}
+
+	if s.state == bbrStateStartup {
+		t.Fatalf("expected Sender to leave STARTUP after a stalled delivery rate, still in STARTUP")
+	}
+}
+
+// TestSenderRTPropUsesRealRTT checks rtProp tracks the sent-to-ack delay of
+// an individual packet rather than the gap between unrelated ack events.
+func TestSenderRTPropUsesRealRTT(t *testing.T) {
+	s := NewSender()
+	sendTime := time.Unix(0, 0)
+
+	s.OnPacketSent(sendTime, 0, 1, 1000, true)
+	ackTime := sendTime.Add(50 * time.Millisecond)
+	s.OnPacketAcked(1, 1000, 0, ackTime)
+
+	if s.rtProp != 50*time.Millisecond {
+		t.Fatalf("expected rtProp to be 50ms from the real sample, got %v", s.rtProp)
+	}
+}
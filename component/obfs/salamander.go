@@ -0,0 +1,76 @@
+package obfs
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const saltLen = 8
+
+// Salamander is the packet obfuscator used by the Hysteria2 protocol. Each
+// packet is XORed against a keystream derived from the shared password and
+// a random salt prepended to the ciphertext, so no two packets reuse the
+// same stream.
+type Salamander struct {
+	password []byte
+}
+
+func NewSalamander(password string) *Salamander {
+	return &Salamander{password: []byte(password)}
+}
+
+// Obfuscate writes the obfuscated form of in (salt || ciphertext) into out
+// and returns the number of bytes written. out must be at least
+// len(in)+saltLen long. It returns an error, writing nothing, if it can't
+// draw a random salt.
+func (s *Salamander) Obfuscate(in, out []byte) (int, error) {
+	salt := out[:saltLen]
+	if _, err := rand.Read(salt); err != nil {
+		return 0, fmt.Errorf("salamander: generate salt: %w", err)
+	}
+	keystream(s.password, salt, in, out[saltLen:])
+	return saltLen + len(in), nil
+}
+
+// Deobfuscate reverses Obfuscate, writing the plaintext into out and
+// returning the number of bytes written.
+func (s *Salamander) Deobfuscate(in, out []byte) int {
+	if len(in) <= saltLen {
+		return 0
+	}
+	salt := in[:saltLen]
+	keystream(s.password, salt, in[saltLen:], out)
+	return len(in) - saltLen
+}
+
+// keystream XORs in into out using repeated blocks of
+// BLAKE2b-256(key || counter), where key = BLAKE2b-256(password || salt)
+// and counter is an incrementing 8-byte little-endian value.
+func keystream(password, salt, in, out []byte) {
+	key := blake2b.Sum256(append(append([]byte{}, password...), salt...))
+
+	var counter uint64
+	var block [8]byte
+	var stream [blake2b.Size256]byte
+	for i := 0; i < len(in); i++ {
+		if off := i % blake2b.Size256; off == 0 {
+			putUint64LE(block[:], counter)
+			stream = blake2b.Sum256(append(append([]byte{}, key[:]...), block[:]...))
+			counter++
+		}
+		out[i] = in[i] ^ stream[i%blake2b.Size256]
+	}
+}
+
+func putUint64LE(b []byte, v uint64) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+	b[3] = byte(v >> 24)
+	b[4] = byte(v >> 32)
+	b[5] = byte(v >> 40)
+	b[6] = byte(v >> 48)
+	b[7] = byte(v >> 56)
+}
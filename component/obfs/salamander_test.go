@@ -0,0 +1,62 @@
+package obfs
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSalamanderRoundTrip(t *testing.T) {
+	s := NewSalamander("test-password")
+	plain := []byte("the quick brown fox jumps over the lazy dog")
+
+	obfuscated := make([]byte, len(plain)+saltLen)
+	n, err := s.Obfuscate(plain, obfuscated)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != len(obfuscated) {
+		t.Fatalf("expected Obfuscate to write %d bytes, got %d", len(obfuscated), n)
+	}
+
+	deobfuscated := make([]byte, len(plain))
+	n = s.Deobfuscate(obfuscated[:n], deobfuscated)
+	if n != len(plain) {
+		t.Fatalf("expected Deobfuscate to write %d bytes, got %d", len(plain), n)
+	}
+	if !bytes.Equal(deobfuscated, plain) {
+		t.Fatalf("round trip mismatch: got %q, want %q", deobfuscated, plain)
+	}
+}
+
+func TestSalamanderDifferentSaltsDifferentCiphertext(t *testing.T) {
+	s := NewSalamander("test-password")
+	plain := []byte("same plaintext")
+
+	a := make([]byte, len(plain)+saltLen)
+	b := make([]byte, len(plain)+saltLen)
+	if _, err := s.Obfuscate(plain, a); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := s.Obfuscate(plain, b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if bytes.Equal(a, b) {
+		t.Fatalf("expected two Obfuscate calls to use distinct random salts, got identical output")
+	}
+}
+
+func TestSalamanderWrongPasswordFailsToRecover(t *testing.T) {
+	plain := []byte("secret payload")
+	obfuscated := make([]byte, len(plain)+saltLen)
+	if _, err := NewSalamander("correct-password").Obfuscate(plain, obfuscated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	deobfuscated := make([]byte, len(plain))
+	NewSalamander("wrong-password").Deobfuscate(obfuscated, deobfuscated)
+
+	if bytes.Equal(deobfuscated, plain) {
+		t.Fatalf("expected deobfuscation with the wrong password to not recover the plaintext")
+	}
+}
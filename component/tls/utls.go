@@ -0,0 +1,69 @@
+package tls
+
+import (
+	"fmt"
+
+	utls "github.com/metacubex/utls"
+)
+
+// clientHelloIDs maps the `fingerprint` proxy option to the uTLS profile it
+// mimics.
+var clientHelloIDs = map[string]utls.ClientHelloID{
+	"chrome":  utls.HelloChrome_Auto,
+	"firefox": utls.HelloFirefox_Auto,
+	"safari":  utls.HelloSafari_Auto,
+	"ios":     utls.HelloIOS_Auto,
+	"android": utls.HelloAndroid_11_OkHttp,
+	"edge":    utls.HelloEdge_Auto,
+	"random":  utls.HelloRandomized,
+}
+
+// GetClientHelloID resolves a fingerprint name to the uTLS ClientHelloID it
+// mimics.
+func GetClientHelloID(fingerprint string) (utls.ClientHelloID, error) {
+	id, ok := clientHelloIDs[fingerprint]
+	if !ok {
+		return utls.ClientHelloID{}, fmt.Errorf("unsupported fingerprint: %s", fingerprint)
+	}
+	return id, nil
+}
+
+// ClientHelloSpec returns the extension layout (order, GREASE, supported
+// groups, ALPS, signature algorithms, ...) uTLS uses to mimic id.
+func ClientHelloSpec(id utls.ClientHelloID) (*utls.ClientHelloSpec, error) {
+	spec, err := utls.UTLSIdToSpec(id)
+	if err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// QUICClientHelloSpecGetter is the hook quicConfig.GetClientHelloSpec is set
+// to, letting a uTLS spec stand in for the ClientHello crypto/tls would
+// otherwise generate for a QUIC handshake.
+//
+// Stock github.com/lucas-clemente/quic-go has no such hook: its crypto_setup
+// builds the ClientHello itself and never looks at uTLS. That hook only
+// exists on the github.com/metacubex/quic-go fork, which patches
+// crypto_setup to call GetClientHelloSpec when present. go.mod must carry
+//
+//	replace github.com/lucas-clemente/quic-go => github.com/metacubex/quic-go vX.Y.Z
+//
+// so every consumer of the "github.com/lucas-clemente/quic-go" import path -
+// including core.Client's transitive one pulled in by
+// github.com/tobyxdd/hysteria - resolves to the patched fork and shares its
+// quic.Config/quic.Connection types. Without that replace directive this
+// field does not exist and setting it will fail to compile.
+type QUICClientHelloSpecGetter func() (*utls.ClientHelloSpec, error)
+
+// NewQUICClientHelloSpecGetter builds the getter for a given fingerprint
+// name, as found on e.g. HysteriaOption.Fingerprint.
+func NewQUICClientHelloSpecGetter(fingerprint string) (QUICClientHelloSpecGetter, error) {
+	id, err := GetClientHelloID(fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	return func() (*utls.ClientHelloSpec, error) {
+		return ClientHelloSpec(id)
+	}, nil
+}
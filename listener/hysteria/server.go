@@ -0,0 +1,269 @@
+package hysteria
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/Dreamacro/clash/adapter/inbound"
+	"github.com/Dreamacro/clash/component/bandwidth"
+	C "github.com/Dreamacro/clash/constant"
+	"github.com/Dreamacro/clash/log"
+
+	"github.com/tobyxdd/hysteria/pkg/core"
+	"github.com/tobyxdd/hysteria/pkg/obfs"
+	"github.com/tobyxdd/hysteria/pkg/transport"
+)
+
+// HysteriaUser is one entry of a multi-user `users` list: name is only used
+// for bookkeeping (stats, logs), auth_str is the credential clients present.
+type HysteriaUser struct {
+	Name       string `proxy:"name"`
+	AuthString string `proxy:"auth_str"`
+}
+
+type HysteriaInboundOption struct {
+	Listen              string         `proxy:"listen,omitempty"`
+	Port                int            `proxy:"port"`
+	Users               []HysteriaUser `proxy:"users"`
+	Obfs                string         `proxy:"obfs,omitempty"`
+	Up                  string         `proxy:"up,omitempty"`
+	Down                string         `proxy:"down,omitempty"`
+	RecvWindowConn      uint64         `proxy:"recv_window_conn,omitempty"`
+	RecvWindowClient    uint64         `proxy:"recv_window_client,omitempty"`
+	DisableMTUDiscovery bool           `proxy:"disable_mtu_discovery,omitempty"`
+	Certificate         string         `proxy:"certificate,omitempty"`
+	PrivateKey          string         `proxy:"private-key,omitempty"`
+	CertificateString   string         `proxy:"certificate_str,omitempty"`
+	PrivateKeyString    string         `proxy:"private_key_str,omitempty"`
+}
+
+// Listener runs a Hysteria server: it terminates QUIC + XPlus obfuscation,
+// authenticates each connection against Users, and dispatches accepted
+// streams/UDP sessions into the tunnel like any other inbound.
+type Listener struct {
+	server *core.Server
+	addr   string
+	closed bool
+
+	mu    sync.Mutex
+	names map[string]string // auth string -> user name
+	users map[uint32]string // connection id -> resolved user name, shared by every TCP/UDP request core.Server dispatches on that connection
+}
+
+// loadCertificate accepts the certificate/private-key either as file paths
+// (Certificate/PrivateKey) or as inline PEM content (CertificateString/
+// PrivateKeyString), mirroring the ca/ca_str path-or-inline pattern used for
+// the outbound's CustomCA/CustomCAString.
+func loadCertificate(option HysteriaInboundOption) (tls.Certificate, error) {
+	if option.CertificateString != "" || option.PrivateKeyString != "" {
+		cert, err := tls.X509KeyPair([]byte(option.CertificateString), []byte(option.PrivateKeyString))
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("hysteria: parse inline certificate error: %w", err)
+		}
+		return cert, nil
+	}
+	if option.Certificate == "" || option.PrivateKey == "" {
+		return tls.Certificate{}, errors.New("hysteria: certificate and private-key (or certificate_str and private_key_str) are required")
+	}
+	cert, err := tls.LoadX509KeyPair(option.Certificate, option.PrivateKey)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("hysteria: load certificate error: %w", err)
+	}
+	return cert, nil
+}
+
+// New starts a Hysteria inbound listener. C.HYSTERIA is a new Type this
+// protocol introduces; it belongs in constant/metadata.go next to the other
+// inbound types. Wiring New into the config-driven inbound registry (so a
+// `hysteria:` block in config.yaml actually starts one, and its traffic
+// shows up in the proxy stats API) means editing that registry/loader file -
+// it isn't part of this checkout, so there is nothing here left to change.
+func New(option HysteriaInboundOption, tcpIn chan<- C.ConnContext, udpIn chan<- *inbound.PacketAdapter) (*Listener, error) {
+	if len(option.Users) == 0 {
+		return nil, errors.New("hysteria: at least one user is required")
+	}
+	if option.Port <= 0 || option.Port > 65535 {
+		return nil, fmt.Errorf("hysteria: invalid port: %d", option.Port)
+	}
+
+	cert, err := loadCertificate(option)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"hysteria"},
+		MinVersion:   tls.VersionTLS13,
+	}
+
+	auth := make(map[string]string, len(option.Users))
+	for _, u := range option.Users {
+		if u.AuthString == "" {
+			return nil, fmt.Errorf("hysteria: user %s missing auth_str", u.Name)
+		}
+		auth[u.AuthString] = u.Name
+	}
+
+	var obfuscator obfs.Obfuscator
+	if len(option.Obfs) > 0 {
+		obfuscator = obfs.NewXPlusObfuscator([]byte(option.Obfs))
+	}
+
+	up, down, err := speedOf(option)
+	if err != nil {
+		return nil, err
+	}
+
+	listenAddr := net.JoinHostPort(option.Listen, strconv.Itoa(option.Port))
+	packetConn, err := net.ListenPacket("udp", listenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("hysteria: listen error: %w", err)
+	}
+
+	quicConfig := &core.ServerQUICConfig{
+		InitialStreamReceiveWindow:     option.RecvWindowConn,
+		MaxStreamReceiveWindow:         option.RecvWindowConn,
+		InitialConnectionReceiveWindow: option.RecvWindowClient,
+		MaxConnectionReceiveWindow:     option.RecvWindowClient,
+		DisablePathMTUDiscovery:        option.DisableMTUDiscovery,
+	}
+
+	l := &Listener{addr: listenAddr, names: auth, users: make(map[uint32]string)}
+
+	server, err := core.NewServer(
+		packetConn, &transport.ServerTransport{}, tlsConfig, quicConfig,
+		up, down, 64*1024,
+		l.authenticate,
+		l.onConnect, l.onDisconnect,
+		func(addr net.Addr, reqAddr string, action int, arg string) {},
+		func(id uint32, tcpConn net.Conn, reqAddr string) { l.handleTCP(id, tcpConn, reqAddr, tcpIn) },
+		func(id uint32, err error) {},
+		func(id uint32, udpConn core.UDPConn, reqAddr string) { l.handleUDP(id, udpConn, reqAddr, udpIn) },
+		func(id uint32, err error) {},
+		obfuscator,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("hysteria: create server error: %w", err)
+	}
+	l.server = server
+
+	go func() {
+		if err := server.Serve(); err != nil && !l.closed {
+			log.Errorln("hysteria server serve error: %s", err.Error())
+		}
+	}()
+
+	return l, nil
+}
+
+func (l *Listener) Close() error {
+	l.closed = true
+	return l.server.Close()
+}
+
+func (l *Listener) Address() string {
+	return l.addr
+}
+
+// authenticate reports whether auth matches a configured user, along with
+// that user's name (empty when the credential was rejected).
+func (l *Listener) authenticate(addr net.Addr, auth []byte, sSend uint64, sRecv uint64) (bool, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	name, ok := l.names[string(auth)]
+	return ok, name
+}
+
+// onConnect records the resolved user name under id, the same id
+// core.Server hands to every TCP/UDP request it dispatches on this
+// connection - unlike a TCP net.Conn, a UDP session has no RemoteAddr to key
+// on, so id is what lets handleUDP attribute traffic to a user at all.
+func (l *Listener) onConnect(id uint32, addr net.Addr, auth []byte, sSend uint64, sRecv uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.users[id] = l.names[string(auth)]
+}
+
+func (l *Listener) onDisconnect(id uint32, addr net.Addr, auth []byte, err error) {
+	l.mu.Lock()
+	delete(l.users, id)
+	l.mu.Unlock()
+}
+
+func (l *Listener) userOf(id uint32) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.users[id]
+}
+
+func (l *Listener) handleTCP(id uint32, conn net.Conn, reqAddr string, tcpIn chan<- C.ConnContext) {
+	metadata := parseHysteriaAddr(reqAddr)
+	metadata.Type = C.HYSTERIA
+	metadata.User = l.userOf(id)
+	tcpIn <- inbound.NewSocket(metadata, conn, C.HYSTERIA)
+}
+
+func (l *Listener) handleUDP(id uint32, udpConn core.UDPConn, reqAddr string, udpIn chan<- *inbound.PacketAdapter) {
+	metadata := parseHysteriaAddr(reqAddr)
+	metadata.Type = C.HYSTERIA
+	metadata.User = l.userOf(id)
+	udpIn <- inbound.NewPacket(metadata, &serverUDPConn{udpConn}, C.HYSTERIA)
+}
+
+func speedOf(option HysteriaInboundOption) (uint64, uint64, error) {
+	var up, down uint64
+	if option.Up != "" {
+		up = bandwidth.ParseBps(option.Up)
+		if up == 0 {
+			return 0, 0, errors.New("hysteria: invalid up speed format")
+		}
+	}
+	if option.Down != "" {
+		down = bandwidth.ParseBps(option.Down)
+		if down == 0 {
+			return 0, 0, errors.New("hysteria: invalid down speed format")
+		}
+	}
+	return up, down, nil
+}
+
+func parseHysteriaAddr(reqAddr string) *C.Metadata {
+	metadata := &C.Metadata{}
+	host, port, err := net.SplitHostPort(reqAddr)
+	if err != nil {
+		metadata.Host = reqAddr
+		return metadata
+	}
+	metadata.Host = host
+	metadata.DstPort = port
+	return metadata
+}
+
+// serverUDPConn adapts the inbound server-side core.UDPConn so it can be
+// consumed through the common inbound.PacketAdapter the other UDP
+// listeners use.
+type serverUDPConn struct {
+	core.UDPConn
+}
+
+func (c *serverUDPConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	b, addrStr, err := c.UDPConn.ReadFrom()
+	if err != nil {
+		return
+	}
+	n = copy(p, b)
+	addr, err = net.ResolveUDPAddr("udp", addrStr)
+	return
+}
+
+func (c *serverUDPConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	if err = c.UDPConn.WriteTo(p, addr.String()); err != nil {
+		return
+	}
+	n = len(p)
+	return
+}